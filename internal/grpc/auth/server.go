@@ -3,26 +3,57 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	ssov1 "github.com/batyrnosquare/protos/gen/go/sso"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"sso/internal/logging"
+	"sso/internal/models"
+	"sso/internal/ratelimit"
 	"sso/internal/services/auth"
 )
 
+// registerFullMethod is the fully-qualified method name RegisterRateLimiter
+// applies its TokenBucket to.
+const registerFullMethod = "/auth.Auth/Register"
+
+// RegisterRateLimiter returns a UnaryServerInterceptor that rate-limits the
+// Register RPC only, keyed by the caller's peer address. Wire it into the
+// gRPC server alongside Register.
+func RegisterRateLimiter(limiter *ratelimit.TokenBucket) grpc.UnaryServerInterceptor {
+	return ratelimit.UnaryServerInterceptor(limiter, registerFullMethod)
+}
+
 type Auth interface {
 	Login(
 		ctx context.Context,
 		email string,
 		password string,
 		appID int,
-	) (token string, err error)
+		userAgent string,
+		ip string,
+	) (auth.LoginResult, error)
+	LoginMFA(ctx context.Context, mfaToken string, code string) (token string, refreshToken string, err error)
+	EnrollTOTP(ctx context.Context, userID models.UserID) (secret string, otpauthURL string, recoveryCodes []string, err error)
+	ConfirmTOTP(ctx context.Context, userID models.UserID, code string) error
 	RegisterNewUser(ctx context.Context,
 		email string,
 		password string,
-	) (userID primitive.ObjectID, err error)
-	IsAdmin(ctx context.Context, userID primitive.ObjectID) (bool, error)
+	) (userID models.UserID, err error)
+	IsAdmin(ctx context.Context, userID models.UserID) (bool, error)
+	Refresh(
+		ctx context.Context,
+		refreshToken string,
+		appID int,
+		userAgent string,
+		ip string,
+	) (token string, newRefreshToken string, err error)
+	Logout(ctx context.Context, refreshToken string) error
+	RotateKeys(ctx context.Context) error
+	UnlockAccount(ctx context.Context, email string, appID int) error
 }
 
 type serverAPI struct {
@@ -52,20 +83,172 @@ func (s *serverAPI) Login(
 		return nil, status.Error(codes.InvalidArgument, "app_id is required")
 	}
 
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	result, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()), userAgent(ctx), remoteIP(ctx))
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid argument")
 		}
+
+		var lockedErr *auth.AccountLockedError
+		if errors.As(err, &lockedErr) {
+			grpc.SetTrailer(ctx, metadata.Pairs("retry-after", fmt.Sprintf("%d", int(lockedErr.RetryAfter.Seconds()))))
+			return nil, status.Error(codes.ResourceExhausted, "account locked")
+		}
+
 		return nil, status.Error(codes.Internal, "internal error")
 
 	}
 
+	if result.MFARequired {
+		return &ssov1.LoginResponse{
+			MfaRequired: true,
+			MfaToken:    result.MFAToken,
+		}, nil
+	}
+
 	return &ssov1.LoginResponse{
-		Token: token,
+		Token:        result.Token,
+		RefreshToken: result.RefreshToken,
+	}, nil
+}
+
+func (s *serverAPI) LoginMFA(
+	ctx context.Context,
+	req *ssov1.LoginMFARequest,
+) (*ssov1.LoginMFAResponse, error) {
+	if req.GetMfaToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "mfa_token is required")
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	token, refreshToken, err := s.auth.LoginMFA(ctx, req.GetMfaToken(), req.GetCode())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.Unauthenticated, "invalid mfa code")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.LoginMFAResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *serverAPI) EnrollTOTP(
+	ctx context.Context,
+	req *ssov1.EnrollTOTPRequest,
+) (*ssov1.EnrollTOTPResponse, error) {
+	userID, ok := authUserID(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	ctx = logging.WithUserID(ctx, string(userID))
+
+	secret, otpauthURL, recoveryCodes, err := s.auth.EnrollTOTP(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.EnrollTOTPResponse{
+		Secret:        secret,
+		OtpauthUrl:    otpauthURL,
+		RecoveryCodes: recoveryCodes,
 	}, nil
 }
 
+func (s *serverAPI) ConfirmTOTP(
+	ctx context.Context,
+	req *ssov1.ConfirmTOTPRequest,
+) (*ssov1.ConfirmTOTPResponse, error) {
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	userID, ok := authUserID(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	ctx = logging.WithUserID(ctx, string(userID))
+
+	if err := s.auth.ConfirmTOTP(ctx, userID, req.GetCode()); err != nil {
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid mfa code")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.ConfirmTOTPResponse{}, nil
+}
+
+func (s *serverAPI) Refresh(
+	ctx context.Context,
+	req *ssov1.RefreshRequest,
+) (*ssov1.RefreshResponse, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh_token is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	token, refreshToken, err := s.auth.Refresh(ctx, req.GetRefreshToken(), int(req.GetAppId()), userAgent(ctx), remoteIP(ctx))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.RefreshResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *serverAPI) Logout(
+	ctx context.Context,
+	req *ssov1.LogoutRequest,
+) (*ssov1.LogoutResponse, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh_token is required")
+	}
+
+	if err := s.auth.Logout(ctx, req.GetRefreshToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.LogoutResponse{}, nil
+}
+
+// userAgent extracts the caller's user-agent from incoming gRPC metadata, if any.
+func userAgent(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("user-agent")
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// remoteIP extracts the caller's address from the gRPC peer info, if any.
+func remoteIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
 func (s *serverAPI) Register(
 	ctx context.Context,
 	req *ssov1.RegisterRequest,
@@ -85,22 +268,56 @@ func (s *serverAPI) Register(
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 
-	return &ssov1.RegisterResponse{UserId: userID.Hex()}, nil
+	return &ssov1.RegisterResponse{UserId: string(userID)}, nil
 
 }
 
+func (s *serverAPI) RotateKeys(
+	ctx context.Context,
+	req *ssov1.RotateKeysRequest,
+) (*ssov1.RotateKeysResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.RotateKeys(ctx); err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.RotateKeysResponse{}, nil
+}
+
+func (s *serverAPI) UnlockAccount(
+	ctx context.Context,
+	req *ssov1.UnlockAccountRequest,
+) (*ssov1.UnlockAccountResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if req.GetAppId() == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if err := s.auth.UnlockAccount(ctx, req.GetEmail(), int(req.GetAppId())); err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.UnlockAccountResponse{}, nil
+}
+
 func (s *serverAPI) IsAdmin(
 	ctx context.Context,
 	req *ssov1.IsAdminRequest,
 ) (*ssov1.IsAdminResponse, error) {
-	if req.GetUserId() == primitive.NilObjectID.Hex() {
+	if req.GetUserId() == "" {
 		return nil, status.Error(codes.InvalidArgument, "user_id is required")
 	}
-	userID, err := primitive.ObjectIDFromHex(req.GetUserId())
-	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid user_id")
-	}
-	isAdmin, err := s.auth.IsAdmin(ctx, userID)
+	ctx = logging.WithUserID(ctx, req.GetUserId())
+	isAdmin, err := s.auth.IsAdmin(ctx, models.UserID(req.GetUserId()))
 	if err != nil {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
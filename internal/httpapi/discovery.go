@@ -0,0 +1,72 @@
+// Package httpapi is the HTTP sidecar started next to the gRPC server. It
+// exposes OIDC discovery and JWKS so downstream services can verify access
+// tokens without sharing a secret with this service.
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+
+	"sso/internal/jwt"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwksResponse struct {
+	Keys []jwk `json:"keys"`
+}
+
+type discoveryResponse struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// NewServer builds the discovery/JWKS HTTP server. keys is read live on
+// every request, so a background Rotator can update it without restarting
+// this server.
+func NewServer(addr string, keys *jwt.KeySet, issuer string) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, discoveryResponse{
+			Issuer:  issuer,
+			JWKSURI: issuer + "/.well-known/jwks.json",
+		})
+	})
+
+	mux.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, jwksResponse{Keys: toJWKS(keys.All())})
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+func toJWKS(keys []*jwt.Key) []jwk {
+	out := make([]jwk, 0, len(keys))
+	for _, k := range keys {
+		pub := k.PrivateKey.PublicKey
+		out = append(out, jwk{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: k.KID,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,34 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"sso/internal/models"
+)
+
+// NewToken signs an access token for user/app with the keyset's current
+// signing key, stamping its kid into the header so verifiers know which JWKS
+// entry to use.
+func NewToken(user models.User, app models.App, keys *KeySet, duration time.Duration) (string, error) {
+	key := keys.Current()
+	if key == nil {
+		return "", fmt.Errorf("jwt: no signing key available")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"uid":    string(user.ID),
+		"email":  user.Email,
+		"exp":    time.Now().Add(duration).Unix(),
+		"app_id": app.ID,
+	})
+	token.Header["kid"] = key.KID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"sync"
+	"time"
+)
+
+// Key is a single RSA keypair identified by a stable kid.
+type Key struct {
+	KID        string
+	PrivateKey *rsa.PrivateKey
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+}
+
+// KeySet holds the active signing key plus however many previous keys are
+// still young enough to verify a token they could have signed.
+type KeySet struct {
+	mu       sync.RWMutex
+	current  *Key
+	previous []*Key
+}
+
+// NewKeySet builds a KeySet from keys loaded from a KeyStore, treating the
+// newest non-retired key as current and everything else as verification-only.
+func NewKeySet(keys []*Key) *KeySet {
+	ks := &KeySet{}
+	for _, k := range keys {
+		if k.RetiredAt == nil && (ks.current == nil || k.CreatedAt.After(ks.current.CreatedAt)) {
+			if ks.current != nil {
+				ks.previous = append(ks.previous, ks.current)
+			}
+			ks.current = k
+			continue
+		}
+		ks.previous = append(ks.previous, k)
+	}
+	return ks
+}
+
+// Current returns the key new tokens are signed with, or nil if none has
+// been generated yet.
+func (ks *KeySet) Current() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.current
+}
+
+// All returns every key worth publishing in the JWKS: the active signing key
+// plus every previous key that hasn't been pruned yet.
+func (ks *KeySet) All() []*Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	all := make([]*Key, 0, len(ks.previous)+1)
+	if ks.current != nil {
+		all = append(all, ks.current)
+	}
+	return append(all, ks.previous...)
+}
+
+// Rotate makes newKey the active signing key and demotes the previous
+// current key to verification-only.
+func (ks *KeySet) Rotate(newKey *Key) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.current != nil {
+		ks.previous = append(ks.previous, ks.current)
+	}
+	ks.current = newKey
+}
+
+// Prune drops previous keys that a newer key replaced more than maxAge ago;
+// no token still valid could have been signed with them.
+func (ks *KeySet) Prune(maxAge time.Duration) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	kept := make([]*Key, 0, len(ks.previous))
+	for _, k := range ks.previous {
+		// k kept signing until the next newer key replaced it, so maxAge is
+		// measured from that moment rather than from k's own CreatedAt.
+		var replacedAt time.Time
+		for _, other := range append([]*Key{ks.current}, ks.previous...) {
+			if other != nil && other.CreatedAt.After(k.CreatedAt) &&
+				(replacedAt.IsZero() || other.CreatedAt.Before(replacedAt)) {
+				replacedAt = other.CreatedAt
+			}
+		}
+		if replacedAt.IsZero() || replacedAt.After(cutoff) {
+			kept = append(kept, k)
+		}
+	}
+	ks.previous = kept
+}
@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileKeyStore loads/saves PEM-encoded RSA keys from a directory, one file
+// per key named "<kid>.pem". A file's mtime stands in for its created_at.
+type FileKeyStore struct {
+	dir string
+}
+
+func NewFileKeyStore(dir string) *FileKeyStore {
+	return &FileKeyStore{dir: dir}
+}
+
+func (f *FileKeyStore) Load(ctx context.Context) (*KeySet, error) {
+	const op = "jwt.FileKeyStore.Load"
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+
+		raw, err := os.ReadFile(filepath.Join(f.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		priv, err := decodePrivateKeyPEM(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %s: %w", op, kid, err)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		keys = append(keys, &Key{KID: kid, PrivateKey: priv, CreatedAt: info.ModTime()})
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	return NewKeySet(keys), nil
+}
+
+func (f *FileKeyStore) Save(ctx context.Context, key *Key) error {
+	const op = "jwt.FileKeyStore.Save"
+
+	raw := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key.PrivateKey),
+	})
+
+	if err := os.WriteFile(filepath.Join(f.dir, key.KID+".pem"), raw, 0o600); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoKeyStore persists the signing keyset in a "keys" Mongo collection,
+// so instances that Load it share the same keys and rotation survives a
+// restart. Keys are read only by Load: a key another instance saves later is
+// not seen until the next Load.
+type MongoKeyStore struct {
+	DB *mongo.Client
+}
+
+func NewMongoKeyStore(db *mongo.Client) *MongoKeyStore {
+	return &MongoKeyStore{DB: db}
+}
+
+func (m *MongoKeyStore) collection() *mongo.Collection {
+	return m.DB.Database("pizzeria").Collection("keys")
+}
+
+type keyDocument struct {
+	KID        string     `bson:"_id"`
+	PrivatePEM string     `bson:"private_pem"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	RetiredAt  *time.Time `bson:"retired_at,omitempty"`
+}
+
+func (m *MongoKeyStore) Load(ctx context.Context) (*KeySet, error) {
+	const op = "jwt.MongoKeyStore.Load"
+
+	cur, err := m.collection().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer cur.Close(ctx)
+
+	var keys []*Key
+	for cur.Next(ctx) {
+		var doc keyDocument
+		if err := cur.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		key, err := decodeKeyDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys), nil
+}
+
+func (m *MongoKeyStore) Save(ctx context.Context, key *Key) error {
+	const op = "jwt.MongoKeyStore.Save"
+
+	doc := keyDocument{
+		KID:        key.KID,
+		PrivatePEM: string(encodePrivateKeyPEM(key)),
+		CreatedAt:  key.CreatedAt,
+		RetiredAt:  key.RetiredAt,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func decodeKeyDocument(doc keyDocument) (*Key, error) {
+	priv, err := decodePrivateKeyPEM([]byte(doc.PrivatePEM))
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", doc.KID, err)
+	}
+	return &Key{KID: doc.KID, PrivateKey: priv, CreatedAt: doc.CreatedAt, RetiredAt: doc.RetiredAt}, nil
+}
+
+func encodePrivateKeyPEM(key *Key) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key.PrivateKey),
+	})
+}
+
+func decodePrivateKeyPEM(raw []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: invalid PEM block")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const rsaKeyBits = 2048
+
+// KeyStore is whatever can persist a newly generated signing key: a
+// MongoKeyStore or a FileKeyStore.
+type KeyStore interface {
+	Save(ctx context.Context, key *Key) error
+}
+
+// Rotator periodically mints a new signing key, publishes it as the active
+// key, and prunes previous keys old enough that no still-valid token could
+// have been signed with them.
+type Rotator struct {
+	keys        *KeySet
+	store       KeyStore
+	maxTokenTTL time.Duration
+	log         *slog.Logger
+}
+
+func NewRotator(keys *KeySet, store KeyStore, maxTokenTTL time.Duration, log *slog.Logger) *Rotator {
+	return &Rotator{keys: keys, store: store, maxTokenTTL: maxTokenTTL, log: log}
+}
+
+// Rotate generates a new signing key, persists it, makes it current, and
+// prunes previous keys that have outlived every token they could have signed.
+func (r *Rotator) Rotate(ctx context.Context) error {
+	const op = "jwt.Rotator.Rotate"
+
+	priv, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	kid, err := newKID()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := &Key{KID: kid, PrivateKey: priv, CreatedAt: time.Now()}
+
+	if err := r.store.Save(ctx, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	r.keys.Rotate(key)
+	r.keys.Prune(r.maxTokenTTL)
+
+	r.log.Info("rotated signing key", slog.String("kid", kid))
+
+	return nil
+}
+
+// Run rotates on a fixed interval until ctx is cancelled.
+func (r *Rotator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Rotate(ctx); err != nil {
+				r.log.Error("failed to rotate signing key", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+func newKID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
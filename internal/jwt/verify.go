@@ -0,0 +1,48 @@
+package jwt
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims is the decoded payload of an access token minted by NewToken.
+type Claims struct {
+	UserID string
+	Email  string
+	AppID  int
+}
+
+// Verify parses and validates tokenString, matching its kid header against
+// keys' active signing key or any still-unpruned previous key, and returns
+// its claims. It rejects anything but an RS256 token, an unknown kid, a bad
+// signature, or an expired token.
+func Verify(tokenString string, keys *KeySet) (*Claims, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
+		if t.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("jwt: unexpected signing method %v", t.Header["alg"])
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		for _, key := range keys.All() {
+			if key.KID == kid {
+				return &key.PrivateKey.PublicKey, nil
+			}
+		}
+		return nil, fmt.Errorf("jwt: unknown key id %q", kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("jwt: invalid token")
+	}
+
+	uid, _ := claims["uid"].(string)
+	email, _ := claims["email"].(string)
+	appID, _ := claims["app_id"].(float64)
+
+	return &Claims{UserID: uid, Email: email, AppID: int(appID)}, nil
+}
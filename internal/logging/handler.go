@@ -0,0 +1,17 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+)
+
+// NewHandler builds a slog.Handler for the given config-driven format
+// ("json" or "text"; anything else falls back to text) at level.
+func NewHandler(format string, w io.Writer, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryServerInterceptor seeds every incoming call's context with a logger
+// carrying request_id, remote_addr, and method, then logs the outcome once
+// the handler returns.
+func UnaryServerInterceptor(base *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = WithFields(ctx, base, map[string]string{
+			"request_id":  newRequestID(),
+			"remote_addr": remoteAddr(ctx),
+			"method":      info.FullMethod,
+		})
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		log := L(ctx).With(slog.Duration("duration", time.Since(start)))
+		if err != nil {
+			log.Error("rpc failed", slog.Any("err", err))
+		} else {
+			log.Info("rpc completed")
+		}
+
+		return resp, err
+	}
+}
+
+func remoteAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
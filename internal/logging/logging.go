@@ -0,0 +1,75 @@
+// Package logging provides the request-scoped slog.Logger threaded through
+// context.Context across services/auth and grpc/auth, plus the gRPC
+// interceptor that seeds it with request_id/remote_addr/method (and, once a
+// handler knows who's calling, user_id).
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type ctxKey struct{}
+
+type state struct {
+	logger *slog.Logger
+	fields map[string]string
+}
+
+// L returns the logger attached to ctx by the interceptor (or WithFields),
+// falling back to slog.Default() so call sites never need a nil check.
+func L(ctx context.Context) *slog.Logger {
+	if s, ok := ctx.Value(ctxKey{}).(*state); ok {
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// FieldsFromContext returns the structured fields attached to ctx so far
+// (request_id, remote_addr, method, user_id, ...), for callers that need the
+// raw values rather than a logger to write through.
+func FieldsFromContext(ctx context.Context) map[string]string {
+	s, ok := ctx.Value(ctxKey{}).(*state)
+	if !ok {
+		return nil
+	}
+
+	fields := make(map[string]string, len(s.fields))
+	for k, v := range s.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// WithFields attaches logger, enriched with fields, to ctx; it's how the
+// interceptor seeds the per-RPC logger. Fields already present on ctx are
+// carried forward and overridden by any key repeated in fields.
+func WithFields(ctx context.Context, logger *slog.Logger, fields map[string]string) context.Context {
+	merged := FieldsFromContext(ctx)
+	if merged == nil {
+		merged = make(map[string]string, len(fields))
+	}
+
+	attrs := make([]any, 0, len(fields)*2)
+	for k, v := range fields {
+		merged[k] = v
+		attrs = append(attrs, slog.String(k, v))
+	}
+
+	return context.WithValue(ctx, ctxKey{}, &state{
+		logger: logger.With(attrs...),
+		fields: merged,
+	})
+}
+
+// WithRequestID attaches request_id to the logger already in ctx.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return WithFields(ctx, L(ctx), map[string]string{"request_id": requestID})
+}
+
+// WithUserID attaches user_id to the logger already in ctx, once a handler
+// knows which user the call is about (e.g. the authenticated caller in
+// EnrollTOTP, or the requested user_id in IsAdmin).
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return WithFields(ctx, L(ctx), map[string]string{"user_id": userID})
+}
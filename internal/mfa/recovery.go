@@ -0,0 +1,25 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// GenerateRecoveryCodes returns n single-use recovery codes formatted as
+// "xxxxx-xxxxx", suitable for display to the user once and hashing for
+// storage thereafter.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(buf)
+		codes[i] = fmt.Sprintf("%s-%s", raw[:5], raw[5:])
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// ID identifies a stored record independently of which storage backend
+// produced it (a Mongo ObjectID hex string, a random hex string in SQLite).
+type ID string
+
+// UserID identifies a user across any storage backend.
+type UserID = ID
+
+type User struct {
+	ID       UserID `bson:"_id,omitempty"`
+	Email    string `bson:"email"`
+	PassHash []byte `bson:"pass_hash"`
+
+	// MFASecretEnc is the user's TOTP secret, encrypted at rest; empty until
+	// EnrollTOTP runs. MFAEnabled only flips true once ConfirmTOTP verifies a
+	// code against it. RecoveryHashes are single-use, hashed with the same
+	// Hasher as passwords.
+	MFASecretEnc   string   `bson:"mfa_secret_enc,omitempty"`
+	MFAEnabled     bool     `bson:"mfa_enabled"`
+	RecoveryHashes []string `bson:"recovery_hashes,omitempty"`
+}
+
+type App struct {
+	ID     int    `bson:"id"`
+	Name   string `bson:"name"`
+	Secret string `bson:"secret"`
+}
+
+// RefreshToken is a single node in a user's refresh chain for a given app.
+// Only TokenHash is ever persisted; the plaintext token is returned to the
+// caller once and never stored.
+type RefreshToken struct {
+	ID         ID         `bson:"_id,omitempty"`
+	UserID     UserID     `bson:"user_id"`
+	AppID      int        `bson:"app_id"`
+	TokenHash  string     `bson:"token_hash"`
+	IssuedAt   time.Time  `bson:"issued_at"`
+	ExpiresAt  time.Time  `bson:"expires_at"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty"`
+	ReplacedBy ID         `bson:"replaced_by,omitempty"`
+	UserAgent  string     `bson:"user_agent"`
+	IP         string     `bson:"ip"`
+}
@@ -0,0 +1,33 @@
+package password
+
+import "github.com/alexedwards/argon2id"
+
+// Argon2idHasher is the default Hasher: RegisterNewUser and the
+// bcrypt-migration path on Login both hash with it.
+type Argon2idHasher struct {
+	params *argon2id.Params
+}
+
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8, saltLength, keyLength uint32) *Argon2idHasher {
+	return &Argon2idHasher{
+		params: &argon2id.Params{
+			Memory:      memory,
+			Iterations:  iterations,
+			Parallelism: parallelism,
+			SaltLength:  saltLength,
+			KeyLength:   keyLength,
+		},
+	}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	return argon2id.CreateHash(password, h.params)
+}
+
+func (h *Argon2idHasher) Verify(password, hash string) (bool, error) {
+	match, _, err := argon2id.CheckHash(password, hash)
+	if err != nil {
+		return false, err
+	}
+	return match, nil
+}
@@ -0,0 +1,47 @@
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptPrefix identifies legacy bcrypt hashes ("$2a$10$...", "$2b$...",
+// ...), as opposed to the PHC argon2id format ("$argon2id$...").
+const bcryptPrefix = "$2"
+
+// IsBcryptHash reports whether hash was produced by BcryptHasher rather than
+// the current default Hasher, so Login knows when a migration is due.
+func IsBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, bcryptPrefix)
+}
+
+// BcryptHasher is kept only to verify hashes written before the argon2id
+// migration; RegisterNewUser no longer produces bcrypt hashes.
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
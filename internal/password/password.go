@@ -0,0 +1,13 @@
+// Package password abstracts password hashing behind a Hasher interface so
+// the service can run a strong default (argon2id) while still verifying
+// hashes written by an older algorithm (bcrypt), migrating them on next login.
+package password
+
+// Hasher hashes and verifies passwords. Every hash it produces is
+// self-describing (PHC string format for argon2id, the "$2a$..." modular
+// crypt format for bcrypt), so a Hasher only ever needs to verify hashes it
+// itself produced.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, error)
+}
@@ -0,0 +1,90 @@
+// Package ratelimit provides the per-peer token bucket used to blunt
+// enumeration/signup floods and the pluggable lockout store behind
+// per-account brute-force protection.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a per-key token bucket: each key gets its own bucket that
+// refills at rate tokens per interval, up to burst capacity.
+type TokenBucket struct {
+	mu       sync.Mutex
+	rate     int
+	burst    int
+	interval time.Duration
+	buckets  map[string]*bucket
+}
+
+type bucket struct {
+	tokens   int
+	lastFill time.Time
+}
+
+func NewTokenBucket(rate, burst int, interval time.Duration) *TokenBucket {
+	return &TokenBucket{
+		rate:     rate,
+		burst:    burst,
+		interval: interval,
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// Allow reports whether key may proceed, consuming one token if so.
+func (tb *TokenBucket) Allow(key string) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.burst, lastFill: time.Now()}
+		tb.buckets[key] = b
+	}
+
+	if elapsed := time.Since(b.lastFill); elapsed >= tb.interval {
+		refill := int(elapsed/tb.interval) * tb.rate
+		b.tokens = min(b.tokens+refill, tb.burst)
+		b.lastFill = time.Now()
+	}
+
+	if b.tokens <= 0 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops buckets idle for longer than maxIdle: they're back to a full
+// (or default) token count anyway, so keeping them around only grows
+// buckets without bound for callers that never come back.
+func (tb *TokenBucket) sweep(maxIdle time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxIdle)
+	for key, b := range tb.buckets {
+		if b.lastFill.Before(cutoff) {
+			delete(tb.buckets, key)
+		}
+	}
+}
+
+// Run sweeps idle buckets on a fixed interval until ctx is cancelled. Wire
+// it up alongside NewTokenBucket so long-running processes don't accumulate
+// one bucket per distinct caller forever.
+func (tb *TokenBucket) Run(ctx context.Context, interval, maxIdle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tb.sweep(maxIdle)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor applies limiter, keyed by remote peer host, to
+// every call to fullMethod (e.g. "/auth.Auth/Register"); all other methods
+// pass through untouched.
+func UnaryServerInterceptor(limiter *TokenBucket, fullMethod string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if info.FullMethod != fullMethod {
+			return handler(ctx, req)
+		}
+
+		if !limiter.Allow(peerAddr(ctx)) {
+			return nil, status.Error(codes.ResourceExhausted, "too many requests")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// peerAddr returns the caller's host, with the ephemeral port stripped: a
+// client that reconnects per request (the common case for abuse tooling)
+// otherwise gets a fresh TokenBucket key on every single call.
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
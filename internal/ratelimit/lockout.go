@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LockoutStore tracks failed login attempts per key and enforces a lockout
+// window once a caller exceeds the configured threshold. Auth.Login counts
+// and locks on the same (email, app_id) key, so an attacker can't dodge the
+// lockout threshold by rotating source IPs.
+type LockoutStore interface {
+	// RecordFailure increments the failure counter for key, resetting it
+	// first if the current window is older than window, and returns the
+	// new count.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (attempts int, err error)
+	Reset(ctx context.Context, key string) error
+	LockedUntil(ctx context.Context, key string) (until time.Time, locked bool, err error)
+	// Lock locks key until the given time; a zero time clears any lock,
+	// which is how admin unlock is implemented.
+	Lock(ctx context.Context, key string, until time.Time) error
+}
+
+// MemoryLockoutStore is the default LockoutStore: per-process, reset on
+// restart. Good enough for a single instance; use a Redis-backed
+// LockoutStore when running more than one.
+type MemoryLockoutStore struct {
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	attempts    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+func NewMemoryLockoutStore() *MemoryLockoutStore {
+	return &MemoryLockoutStore{entries: make(map[string]*lockoutEntry)}
+}
+
+func (m *MemoryLockoutStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e, ok := m.entries[key]
+	if !ok || now.Sub(e.windowStart) > window {
+		e = &lockoutEntry{windowStart: now}
+		m.entries[key] = e
+	}
+	e.attempts++
+
+	return e.attempts, nil
+}
+
+func (m *MemoryLockoutStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemoryLockoutStore) LockedUntil(ctx context.Context, key string) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || e.lockedUntil.IsZero() || time.Now().After(e.lockedUntil) {
+		return time.Time{}, false, nil
+	}
+	return e.lockedUntil, true, nil
+}
+
+func (m *MemoryLockoutStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		e = &lockoutEntry{}
+		m.entries[key] = e
+	}
+	e.lockedUntil = until
+
+	return nil
+}
+
+// sweep drops entries whose failure window has elapsed and whose lock (if
+// any) has expired, so callers that never succeed or get explicitly Reset
+// don't grow this map forever.
+func (m *MemoryLockoutStore) sweep(maxAge time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-maxAge)
+	for key, e := range m.entries {
+		if e.windowStart.After(cutoff) {
+			continue
+		}
+		if !e.lockedUntil.IsZero() && e.lockedUntil.After(now) {
+			continue
+		}
+		delete(m.entries, key)
+	}
+}
+
+// Run sweeps expired entries on a fixed interval until ctx is cancelled.
+func (m *MemoryLockoutStore) Run(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sweep(maxAge)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLockoutStore is the multi-instance-safe LockoutStore: attempts and
+// locks live in Redis instead of process memory, so every instance of the
+// service agrees on lockout state.
+type RedisLockoutStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisLockoutStore(client *redis.Client, prefix string) *RedisLockoutStore {
+	return &RedisLockoutStore{client: client, prefix: prefix}
+}
+
+func (r *RedisLockoutStore) attemptsKey(key string) string {
+	return fmt.Sprintf("%s:attempts:%s", r.prefix, key)
+}
+
+func (r *RedisLockoutStore) lockKey(key string) string {
+	return fmt.Sprintf("%s:lock:%s", r.prefix, key)
+}
+
+func (r *RedisLockoutStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	k := r.attemptsKey(key)
+
+	attempts, err := r.client.Incr(ctx, k).Result()
+	if err != nil {
+		return 0, err
+	}
+	if attempts == 1 {
+		if err := r.client.Expire(ctx, k, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(attempts), nil
+}
+
+func (r *RedisLockoutStore) Reset(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.attemptsKey(key)).Err()
+}
+
+func (r *RedisLockoutStore) LockedUntil(ctx context.Context, key string) (time.Time, bool, error) {
+	ttl, err := r.client.TTL(ctx, r.lockKey(key)).Result()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if ttl <= 0 {
+		return time.Time{}, false, nil
+	}
+
+	return time.Now().Add(ttl), true, nil
+}
+
+func (r *RedisLockoutStore) Lock(ctx context.Context, key string, until time.Time) error {
+	if until.IsZero() {
+		return r.client.Del(ctx, r.lockKey(key)).Err()
+	}
+	return r.client.Set(ctx, r.lockKey(key), "1", time.Until(until)).Err()
+}
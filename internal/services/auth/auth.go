@@ -2,58 +2,198 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
 	"sso/internal/jwt"
+	"sso/internal/logging"
+	"sso/internal/mfa"
 	"sso/internal/models"
+	"sso/internal/password"
+	"sso/internal/ratelimit"
 	"sso/internal/storage"
+	"sync"
 	"time"
 )
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	userSaver            UserSaver
+	userProvider         UserProvider
+	appProvider          AppProvider
+	refreshTokenSaver    RefreshTokenSaver
+	refreshTokenProvider RefreshTokenProvider
+	refreshTokenRevoker  RefreshTokenRevoker
+	keys                 *jwt.KeySet
+	rotator              *jwt.Rotator
+	hasher               password.Hasher
+	legacyHasher         password.Hasher
+	lockoutStore         ratelimit.LockoutStore
+	maxAttempts          int
+	lockoutWindow        time.Duration
+	lockoutDuration      time.Duration
+	mfaEncryptor         mfa.Encryptor
+	mfaIssuer            string
+	recoveryCodeCount    int
+	mfaTokenTTL          time.Duration
+	mfaPendingMu         sync.Mutex
+	mfaPending           map[string]mfaPendingLogin
+	tokenTTL             time.Duration
+	refreshTokenTTL      time.Duration
+}
+
+// mfaPendingLogin is the server-side state behind an opaque mfa_token handed
+// back by Login while MFA is outstanding: just enough to finish the login
+// once LoginMFA presents a valid code. It never touches storage since it's
+// only needed for the few minutes a user takes to open their authenticator.
+type mfaPendingLogin struct {
+	userID    models.UserID
+	appID     int
+	userAgent string
+	ip        string
+	expiresAt time.Time
 }
 
 type UserSaver interface {
-	SaveUser(ctx context.Context, email string, passHash []byte) (primitive.ObjectID, error)
+	SaveUser(ctx context.Context, email string, passHash []byte) (models.UserID, error)
 }
 
 type UserProvider interface {
 	UserByEmail(ctx context.Context, email string) (models.User, error)
-	IsAdmin(ctx context.Context, userID primitive.ObjectID) (bool, error)
+	UserByID(ctx context.Context, userID models.UserID) (models.User, error)
+	IsAdmin(ctx context.Context, userID models.UserID) (bool, error)
+	UpdatePassword(ctx context.Context, userID models.UserID, passHash []byte) error
+	SetMFASecret(ctx context.Context, userID models.UserID, secretEnc string, recoveryHashes []string) error
+	EnableMFA(ctx context.Context, userID models.UserID) error
+	ConsumeRecoveryCode(ctx context.Context, userID models.UserID, hash string) error
 }
 
 type AppProvider interface {
 	App(ctx context.Context, appID int) (models.App, error)
 }
 
+// RefreshTokenSaver persists a newly issued refresh token.
+type RefreshTokenSaver interface {
+	SaveRefreshToken(ctx context.Context, rt models.RefreshToken) error
+}
+
+// RefreshTokenProvider looks up a refresh token by the hash of its plaintext value.
+type RefreshTokenProvider interface {
+	RefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+}
+
+// RefreshTokenRevoker marks refresh tokens revoked, either individually (rotation)
+// or as a whole user+app chain (reuse detection).
+type RefreshTokenRevoker interface {
+	RevokeRefreshToken(ctx context.Context, id models.ID, replacedBy models.ID) error
+	// RevokeRefreshTokenIfActive atomically revokes id, but only if it
+	// hasn't already been revoked by a concurrent call; ok reports whether
+	// this call was the one that revoked it. A false ok with a nil error
+	// means someone else revoked id first — i.e. the token was reused.
+	RevokeRefreshTokenIfActive(ctx context.Context, id models.ID, replacedBy models.ID) (ok bool, err error)
+	RevokeRefreshTokenChain(ctx context.Context, userID models.UserID, appID int) error
+}
+
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidAppID       = errors.New("invalid app id")
 	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrAccountLocked      = errors.New("account locked")
 )
 
+// AccountLockedError is returned by Login once the account has crossed the
+// failed-attempt threshold; RetryAfter tells the caller how long the lockout
+// has left to run.
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account locked, retry after %s", e.RetryAfter)
+}
+
+func (e *AccountLockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
 func New(
-	log *slog.Logger,
 	userSaver UserSaver,
 	userProvider UserProvider,
 	appProvider AppProvider,
+	refreshTokenSaver RefreshTokenSaver,
+	refreshTokenProvider RefreshTokenProvider,
+	refreshTokenRevoker RefreshTokenRevoker,
+	keys *jwt.KeySet,
+	rotator *jwt.Rotator,
+	hasher password.Hasher,
+	legacyHasher password.Hasher,
+	lockoutStore ratelimit.LockoutStore,
+	maxAttempts int,
+	lockoutWindow time.Duration,
+	lockoutDuration time.Duration,
+	mfaEncryptor mfa.Encryptor,
+	mfaIssuer string,
+	recoveryCodeCount int,
+	mfaTokenTTL time.Duration,
 	tokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
 ) *Auth {
 	return &Auth{
-		userSaver:    userSaver,
-		userProvider: userProvider,
-		log:          log,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		userSaver:            userSaver,
+		userProvider:         userProvider,
+		appProvider:          appProvider,
+		refreshTokenSaver:    refreshTokenSaver,
+		refreshTokenProvider: refreshTokenProvider,
+		refreshTokenRevoker:  refreshTokenRevoker,
+		keys:                 keys,
+		rotator:              rotator,
+		hasher:               hasher,
+		legacyHasher:         legacyHasher,
+		lockoutStore:         lockoutStore,
+		maxAttempts:          maxAttempts,
+		lockoutWindow:        lockoutWindow,
+		lockoutDuration:      lockoutDuration,
+		mfaEncryptor:         mfaEncryptor,
+		mfaIssuer:            mfaIssuer,
+		recoveryCodeCount:    recoveryCodeCount,
+		mfaTokenTTL:          mfaTokenTTL,
+		mfaPending:           make(map[string]mfaPendingLogin),
+		tokenTTL:             tokenTTL,
+		refreshTokenTTL:      refreshTokenTTL,
+	}
+}
+
+// accountKey identifies an (email, app) pair for both counting failed
+// attempts and locking. Counting here, rather than per source IP, means an
+// attacker can't dodge the lockout threshold by rotating IPs; the IP is
+// still logged alongside each failure as a supplementary signal, but it's
+// never part of the key.
+func accountKey(email string, appID int) string {
+	return fmt.Sprintf("%s:%d", email, appID)
+}
+
+// RotateKeys forces an out-of-cycle signing key rotation, independent of the
+// background Rotator's interval.
+func (a *Auth) RotateKeys(ctx context.Context) error {
+	const op = "Auth.RotateKeys"
+
+	if err := a.rotator.Rotate(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	return nil
+}
+
+// LoginResult is what Login returns: either a final token pair, or (when the
+// account has TOTP enabled) MFARequired plus an opaque MFAToken to be handed
+// back to LoginMFA along with the user's TOTP/recovery code.
+type LoginResult struct {
+	Token        string
+	RefreshToken string
+	MFARequired  bool
+	MFAToken     string
 }
 
 func (a *Auth) Login(
@@ -61,90 +201,372 @@ func (a *Auth) Login(
 	email string,
 	password string,
 	appID int,
-) (string, error) {
+	userAgent string,
+	ip string,
+) (LoginResult, error) {
 	const op = "Auth.Login"
 
-	log := a.log.With(
+	log := logging.L(ctx).With(
 		slog.String("op", op),
 		slog.String("username", email),
 	)
 	log.Info("attempting to login user")
 
+	acctKey := accountKey(email, appID)
+
+	if until, locked, err := a.lockoutStore.LockedUntil(ctx, acctKey); err != nil {
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	} else if locked {
+		return LoginResult{}, fmt.Errorf("%s: %w", op, &AccountLockedError{RetryAfter: time.Until(until)})
+	}
+
 	user, err := a.userProvider.UserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			a.log.Warn("user not found", slog.Error)
+			log.Warn("user not found", slog.Any("err", err))
 
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 
-		a.log.Error("failed to get user", slog.Error)
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to get user", slog.Any("err", err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+
+	}
+
+	if err := a.verifyPassword(ctx, &user, password); err != nil {
+		log.Info("invalid credentials", slog.Any("err", err))
 
+		if lockErr := a.recordFailedAttempt(ctx, email, appID, ip); lockErr != nil {
+			return LoginResult{}, fmt.Errorf("%s: %w", op, lockErr)
+		}
+
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials", slog.Error)
+	if err := a.lockoutStore.Reset(ctx, acctKey); err != nil {
+		log.Error("failed to reset login attempts", slog.Any("err", err))
+	}
 
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	if user.MFAEnabled {
+		mfaToken, err := a.issueMFAPending(user.ID, appID, userAgent, ip)
+		if err != nil {
+			return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("mfa required to complete login")
+
+		return LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
 	}
 
-	app, err := a.appProvider.App(ctx, appID)
+	token, refreshToken, err := a.finishLogin(ctx, user, appID, userAgent, ip)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("user logged in successfully")
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	return LoginResult{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// finishLogin mints the access/refresh token pair for an already-authenticated
+// user. Shared by Login (no MFA) and LoginMFA (MFA confirmed).
+func (a *Auth) finishLogin(ctx context.Context, user models.User, appID int, userAgent, ip string) (string, string, error) {
+	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		a.log.Error("failed to generate token", slog.Error)
+		return "", "", err
+	}
 
-		return "", fmt.Errorf("%s: %w", op, err)
+	token, err := jwt.NewToken(user, app, a.keys, a.tokenTTL)
+	if err != nil {
+		logging.L(ctx).Error("failed to generate token", slog.Any("err", err))
+		return "", "", err
+	}
+
+	refreshToken, err := a.issueRefreshToken(ctx, "", user.ID, app.ID, userAgent, ip)
+	if err != nil {
+		logging.L(ctx).Error("failed to issue refresh token", slog.Any("err", err))
+		return "", "", err
+	}
+
+	return token, refreshToken, nil
+}
+
+// verifyPassword checks plaintext against the user's stored hash. Hashes
+// written before the argon2id migration are still bcrypt; those are verified
+// with legacyHasher and transparently re-hashed with the current default
+// Hasher so the next login no longer takes this path.
+func (a *Auth) verifyPassword(ctx context.Context, user *models.User, plaintext string) error {
+	hash := string(user.PassHash)
+
+	if !password.IsBcryptHash(hash) {
+		ok, err := a.hasher.Verify(plaintext, hash)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	ok, err := a.legacyHasher.Verify(plaintext, hash)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	newHash, err := a.hasher.Hash(plaintext)
+	if err != nil {
+		return err
+	}
+	if err := a.userProvider.UpdatePassword(ctx, user.ID, []byte(newHash)); err != nil {
+		logging.L(ctx).Error("failed to migrate password hash to argon2id", slog.Any("err", err))
+	}
+
+	return nil
+}
 
+// recordFailedAttempt counts a failed login attempt and locks the account
+// once maxAttempts is reached within lockoutWindow. ip is logged alongside
+// the failure but deliberately excluded from the counting key: it's only
+// supplementary signal, not something an attacker should be able to reset by
+// rotating. It returns a non-nil error only if the lockout store itself
+// fails, not for the failed-attempt count alone.
+func (a *Auth) recordFailedAttempt(ctx context.Context, email string, appID int, ip string) error {
+	key := accountKey(email, appID)
+
+	attempts, err := a.lockoutStore.RecordFailure(ctx, key, a.lockoutWindow)
+	if err != nil {
+		return err
+	}
+
+	logging.L(ctx).Warn("recorded failed login attempt",
+		slog.String("username", email), slog.String("ip", ip), slog.Int("attempts", attempts))
+
+	if attempts < a.maxAttempts {
+		return nil
 	}
-	return token, nil
 
+	return a.lockoutStore.Lock(ctx, key, time.Now().Add(a.lockoutDuration))
 }
 
-func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (primitive.ObjectID, error) {
+// UnlockAccount clears any lockout on the given (email, app) account,
+// intended for admin-triggered unlocks.
+func (a *Auth) UnlockAccount(ctx context.Context, email string, appID int) error {
+	const op = "Auth.UnlockAccount"
+
+	if err := a.lockoutStore.Lock(ctx, accountKey(email, appID), time.Time{}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// issueRefreshToken generates a new opaque refresh token, persists only its
+// hash, and returns the plaintext value to be handed to the client. If id is
+// non-empty, it's used as the new row's ID instead of letting the storage
+// backend generate one; Refresh relies on this to revoke the old token with
+// replaced_by already pointing at the new one before the new row exists.
+func (a *Auth) issueRefreshToken(ctx context.Context, id models.ID, userID models.UserID, appID int, userAgent, ip string) (string, error) {
+	plaintext, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	rt := models.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		AppID:     appID,
+		TokenHash: hashToken(plaintext),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(a.refreshTokenTTL),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+
+	if err := a.refreshTokenSaver.SaveRefreshToken(ctx, rt); err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Refresh exchanges a still-valid refresh token for a new access/refresh
+// token pair, rotating the refresh token in the process. Presenting a token
+// that has already been rotated or revoked is treated as reuse: the entire
+// chain for that user+app is revoked and the request is rejected.
+func (a *Auth) Refresh(
+	ctx context.Context,
+	refreshToken string,
+	appID int,
+	userAgent string,
+	ip string,
+) (string, string, error) {
+	const op = "Auth.Refresh"
+
+	log := logging.L(ctx).With(slog.String("op", op))
+
+	rt, err := a.refreshTokenProvider.RefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rt.AppID != appID {
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if rt.RevokedAt != nil {
+		log.Warn("revoked refresh token presented, revoking chain", slog.String("user_id", string(rt.UserID)))
+
+		if err := a.refreshTokenRevoker.RevokeRefreshTokenChain(ctx, rt.UserID, rt.AppID); err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if time.Now().After(rt.ExpiresAt) {
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	newID, err := newRefreshTokenID()
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Revoke the presented token before issuing its replacement, and make
+	// the revoke conditional on it still being active. Two concurrent
+	// Refresh calls can both pass the RevokedAt check above for the same
+	// token; only one of them can win this compare-and-swap, and the loser
+	// must be treated as reuse rather than silently minting a second live
+	// token off the same parent.
+	ok, err := a.refreshTokenRevoker.RevokeRefreshTokenIfActive(ctx, rt.ID, models.ID(newID))
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		log.Warn("refresh token reused concurrently, revoking chain", slog.String("user_id", string(rt.UserID)))
+
+		if err := a.refreshTokenRevoker.RevokeRefreshTokenChain(ctx, rt.UserID, rt.AppID); err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	app, err := a.appProvider.App(ctx, rt.AppID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, rt.UserID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	newRefreshToken, err := a.issueRefreshToken(ctx, models.ID(newID), rt.UserID, rt.AppID, userAgent, ip)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	newToken, err := jwt.NewToken(user, app, a.keys, a.tokenTTL)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("refresh token rotated", slog.String("user_id", string(rt.UserID)))
+
+	return newToken, newRefreshToken, nil
+}
+
+// Logout revokes the presented refresh token so it can no longer be used to
+// mint new access tokens.
+func (a *Auth) Logout(ctx context.Context, refreshToken string) error {
+	const op = "Auth.Logout"
+
+	rt, err := a.refreshTokenProvider.RefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.refreshTokenRevoker.RevokeRefreshToken(ctx, rt.ID, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func newOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newRefreshTokenID mints the storage ID for a refresh token before it's
+// saved, so Refresh can point the old token's replaced_by at it as part of
+// the same atomic revoke that retires that old token.
+func newRefreshTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (models.UserID, error) {
 	const op = "auth.RegisterNewUser"
 
-	log := a.log.With(
+	log := logging.L(ctx).With(
 		slog.String("op", op),
 		slog.String("email", email),
 	)
 
 	log.Info("registering user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	passHash, err := a.hasher.Hash(pass)
 
 	if err != nil {
-		log.Error("failed to generate password hash", slog.Error)
-		return primitive.NilObjectID, fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to generate password hash", slog.Any("err", err))
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, err := a.userSaver.SaveUser(ctx, email, passHash)
+	id, err := a.userSaver.SaveUser(ctx, email, []byte(passHash))
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
-			log.Warn("user already exists", slog.Error)
+			log.Warn("user already exists", slog.Any("err", err))
 
-			return primitive.NilObjectID, fmt.Errorf("%s: %w", op, ErrUserExists)
+			return "", fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
-		log.Error("failed to save user", slog.Error)
-		return primitive.NilObjectID, fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to save user", slog.Any("err", err))
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("user registered")
 	return id, nil
 }
 
-func (a *Auth) IsAdmin(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+func (a *Auth) IsAdmin(ctx context.Context, userID models.UserID) (bool, error) {
 	const op = "auth.IsAdmin"
 
-	log := a.log.With(
+	log := logging.L(ctx).With(
 		slog.String("op", op),
-		slog.String("user_id", userID.Hex()),
+		slog.String("user_id", string(userID)),
 	)
 
 	log.Info("checking if user is admin")
@@ -152,7 +574,7 @@ func (a *Auth) IsAdmin(ctx context.Context, userID primitive.ObjectID) (bool, er
 	isAdmin, err := a.userProvider.IsAdmin(ctx, userID)
 	if err != nil {
 		if errors.Is(err, storage.ErrAppNotFound) {
-			log.Warn("user not found", slog.Error)
+			log.Warn("user not found", slog.Any("err", err))
 		}
 
 		return false, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/models"
+	"sso/internal/ratelimit"
+)
+
+type fakeUserProvider struct {
+	user models.User
+}
+
+func (f *fakeUserProvider) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	return f.user, nil
+}
+
+func (f *fakeUserProvider) UserByID(ctx context.Context, userID models.UserID) (models.User, error) {
+	return f.user, nil
+}
+
+func (f *fakeUserProvider) IsAdmin(ctx context.Context, userID models.UserID) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeUserProvider) UpdatePassword(ctx context.Context, userID models.UserID, passHash []byte) error {
+	return nil
+}
+
+func (f *fakeUserProvider) SetMFASecret(ctx context.Context, userID models.UserID, secretEnc string, recoveryHashes []string) error {
+	return nil
+}
+
+func (f *fakeUserProvider) EnableMFA(ctx context.Context, userID models.UserID) error { return nil }
+
+func (f *fakeUserProvider) ConsumeRecoveryCode(ctx context.Context, userID models.UserID, hash string) error {
+	return nil
+}
+
+// rejectHasher never matches, so every login attempt in these tests fails
+// password verification the same way a wrong password would.
+type rejectHasher struct{}
+
+func (rejectHasher) Hash(password string) (string, error) { return password, nil }
+
+func (rejectHasher) Verify(password, hash string) (bool, error) { return false, nil }
+
+// TestLogin_LockoutCountsPerAccountNotPerIP is the regression test for the
+// per-IP counting bug: failures used to be counted per (email, app, ip), so an
+// attacker rotating source IP never reached maxAttempts. Counting must
+// happen per (email, app) regardless of how many IPs are involved.
+func TestLogin_LockoutCountsPerAccountNotPerIP(t *testing.T) {
+	lockoutStore := ratelimit.NewMemoryLockoutStore()
+	a := New(
+		nil,
+		&fakeUserProvider{user: models.User{ID: "u1", Email: "a@example.com", PassHash: []byte("hash")}},
+		nil,
+		nil, nil, nil,
+		nil, nil,
+		rejectHasher{}, rejectHasher{},
+		lockoutStore, 3, time.Minute, time.Minute,
+		nil, "issuer", 5, time.Minute,
+		time.Minute, time.Hour,
+	)
+
+	ctx := context.Background()
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		if _, err := a.Login(ctx, "a@example.com", "wrong", 1, "ua", ip); err == nil {
+			t.Fatalf("attempt %d: expected login to fail", i)
+		}
+	}
+
+	if _, locked, err := lockoutStore.LockedUntil(ctx, accountKey("a@example.com", 1)); err != nil || !locked {
+		t.Fatalf("expected account locked after 3 failed attempts across different IPs, locked=%v err=%v", locked, err)
+	}
+}
+
+// TestLogin_LockedAccountRejectsBeforePasswordCheck ensures a locked account
+// rejects immediately instead of re-verifying the password.
+func TestLogin_LockedAccountRejectsBeforePasswordCheck(t *testing.T) {
+	lockoutStore := ratelimit.NewMemoryLockoutStore()
+	if err := lockoutStore.Lock(context.Background(), accountKey("a@example.com", 1), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to seed lock: %v", err)
+	}
+
+	a := New(
+		nil,
+		&fakeUserProvider{user: models.User{ID: "u1", Email: "a@example.com", PassHash: []byte("hash")}},
+		nil,
+		nil, nil, nil,
+		nil, nil,
+		rejectHasher{}, rejectHasher{},
+		lockoutStore, 3, time.Minute, time.Minute,
+		nil, "issuer", 5, time.Minute,
+		time.Minute, time.Hour,
+	)
+
+	_, err := a.Login(context.Background(), "a@example.com", "wrong", 1, "ua", "1.1.1.1")
+
+	var lockedErr *AccountLockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("expected AccountLockedError, got %v", err)
+	}
+}
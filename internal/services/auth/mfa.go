@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/logging"
+	"sso/internal/mfa"
+	"sso/internal/models"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+var (
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+	ErrMFANotEnabled  = errors.New("mfa not enabled")
+)
+
+// EnrollTOTP generates a new TOTP secret and recovery codes for the user and
+// stores them pending ConfirmTOTP. It does not change mfa_enabled: for a user
+// without MFA it stays off, and re-enrolling overwrites any prior secret and
+// recovery codes, including confirmed ones, while an enabled account stays
+// enabled against the new secret.
+func (a *Auth) EnrollTOTP(ctx context.Context, userID models.UserID) (secret string, otpauthURL string, recoveryCodes []string, err error) {
+	const op = "Auth.EnrollTOTP"
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      a.mfaIssuer,
+		AccountName: user.Email,
+	})
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recoveryCodes, err = mfa.GenerateRecoveryCodes(a.recoveryCodeCount)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recoveryHashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hash, err := a.hasher.Hash(code)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("%s: %w", op, err)
+		}
+		recoveryHashes[i] = hash
+	}
+
+	secretEnc, err := a.mfaEncryptor.Encrypt([]byte(key.Secret()))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userProvider.SetMFASecret(ctx, userID, secretEnc, recoveryHashes); err != nil {
+		return "", "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key.Secret(), key.URL(), recoveryCodes, nil
+}
+
+// ConfirmTOTP verifies a code against the pending secret from EnrollTOTP and,
+// if it matches, flips the account over to MFA-required.
+func (a *Auth) ConfirmTOTP(ctx context.Context, userID models.UserID, code string) error {
+	const op = "Auth.ConfirmTOTP"
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.MFASecretEnc == "" {
+		return fmt.Errorf("%s: %w", op, ErrMFANotEnabled)
+	}
+
+	valid, err := a.validateTOTP(user, code)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !valid {
+		return fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+
+	if err := a.userProvider.EnableMFA(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LoginMFA completes a login started by Login when MFARequired was true,
+// accepting either a current TOTP code or an unused recovery code.
+func (a *Auth) LoginMFA(ctx context.Context, mfaToken string, code string) (string, string, error) {
+	const op = "Auth.LoginMFA"
+
+	pending, ok := a.popMFAPending(mfaToken)
+	if !ok {
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, pending.userID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	valid, err := a.validateTOTP(user, code)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !valid {
+		valid, err = a.consumeRecoveryCode(ctx, &user, code)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if !valid {
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+
+	token, refreshToken, err := a.finishLogin(ctx, user, pending.appID, pending.userAgent, pending.ip)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	logging.L(ctx).Info("mfa login completed", slog.String("user_id", string(user.ID)))
+
+	return token, refreshToken, nil
+}
+
+// validateTOTP decrypts the user's secret and checks code against it,
+// allowing one step of clock drift in either direction.
+func (a *Auth) validateTOTP(user models.User, code string) (bool, error) {
+	if user.MFASecretEnc == "" {
+		return false, nil
+	}
+
+	secret, err := a.mfaEncryptor.Decrypt(user.MFASecretEnc)
+	if err != nil {
+		return false, err
+	}
+
+	valid, err := totp.ValidateCustom(code, string(secret), time.Now(), totp.ValidateOpts{
+		Period:    30,
+		Skew:      1,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	// A code of the wrong length (such as a recovery code) is simply not a
+	// TOTP match, so LoginMFA can go on to try it as a recovery code.
+	if errors.Is(err, otp.ErrValidateInputInvalidLength) {
+		return false, nil
+	}
+	return valid, err
+}
+
+// consumeRecoveryCode checks code against the user's remaining recovery
+// hashes and, on a match, deletes that hash so it can't be reused.
+func (a *Auth) consumeRecoveryCode(ctx context.Context, user *models.User, code string) (bool, error) {
+	for _, hash := range user.RecoveryHashes {
+		ok, err := a.hasher.Verify(code, hash)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := a.userProvider.ConsumeRecoveryCode(ctx, user.ID, hash); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// issueMFAPending stores the server-side state behind an opaque mfa_token
+// and returns the token to hand to the client.
+func (a *Auth) issueMFAPending(userID models.UserID, appID int, userAgent, ip string) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	a.mfaPendingMu.Lock()
+	defer a.mfaPendingMu.Unlock()
+
+	a.mfaPending[token] = mfaPendingLogin{
+		userID:    userID,
+		appID:     appID,
+		userAgent: userAgent,
+		ip:        ip,
+		expiresAt: time.Now().Add(a.mfaTokenTTL),
+	}
+
+	return token, nil
+}
+
+// popMFAPending looks up and removes a pending MFA login; it's single-use
+// regardless of whether the code presented alongside it turns out valid.
+func (a *Auth) popMFAPending(token string) (mfaPendingLogin, bool) {
+	a.mfaPendingMu.Lock()
+	defer a.mfaPendingMu.Unlock()
+
+	pending, ok := a.mfaPending[token]
+	delete(a.mfaPending, token)
+	if !ok || time.Now().After(pending.expiresAt) {
+		return mfaPendingLogin{}, false
+	}
+
+	return pending, true
+}
+
+// sweepMFAPending drops expired pending logins that were never completed
+// with a LoginMFA call, so an abandoned login (or a flood of bogus ones)
+// doesn't grow mfaPending forever.
+func (a *Auth) sweepMFAPending() {
+	a.mfaPendingMu.Lock()
+	defer a.mfaPendingMu.Unlock()
+
+	now := time.Now()
+	for token, pending := range a.mfaPending {
+		if now.After(pending.expiresAt) {
+			delete(a.mfaPending, token)
+		}
+	}
+}
+
+// RunMFAPendingSweeper evicts expired pending MFA logins on a fixed interval
+// until ctx is cancelled.
+func (a *Auth) RunMFAPendingSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.sweepMFAPending()
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"sso/internal/models"
+)
+
+// identityHasher is a trivial password.Hasher for tests that don't need real
+// hashing: Hash is the identity function and Verify is string equality.
+type identityHasher struct{}
+
+func (identityHasher) Hash(password string) (string, error) { return password, nil }
+
+func (identityHasher) Verify(password, hash string) (bool, error) { return password == hash, nil }
+
+type consumeRecoveryCodeCall struct {
+	userID models.UserID
+	hash   string
+}
+
+type fakeRecoveryCodeConsumer struct {
+	fakeUserProvider
+	calls []consumeRecoveryCodeCall
+}
+
+func (f *fakeRecoveryCodeConsumer) ConsumeRecoveryCode(ctx context.Context, userID models.UserID, hash string) error {
+	f.calls = append(f.calls, consumeRecoveryCodeCall{userID: userID, hash: hash})
+	return nil
+}
+
+// TestConsumeRecoveryCode covers both a matching and a non-matching code
+// against the same set of remaining recovery hashes.
+func TestConsumeRecoveryCode(t *testing.T) {
+	up := &fakeRecoveryCodeConsumer{}
+	a := &Auth{hasher: identityHasher{}, userProvider: up}
+	user := &models.User{ID: "u1", RecoveryHashes: []string{"code-a", "code-b"}}
+
+	ok, err := a.consumeRecoveryCode(context.Background(), user, "code-b")
+	if err != nil || !ok {
+		t.Fatalf("expected matching code to be consumed, ok=%v err=%v", ok, err)
+	}
+	if len(up.calls) != 1 || up.calls[0].userID != "u1" || up.calls[0].hash != "code-b" {
+		t.Fatalf("expected ConsumeRecoveryCode(u1, code-b), got %+v", up.calls)
+	}
+
+	ok, err = a.consumeRecoveryCode(context.Background(), user, "not-a-real-code")
+	if err != nil || ok {
+		t.Fatalf("expected unmatched code to report no match, ok=%v err=%v", ok, err)
+	}
+	if len(up.calls) != 1 {
+		t.Fatalf("expected no additional ConsumeRecoveryCode call, got %+v", up.calls)
+	}
+}
+
+// TestConsumeRecoveryCode_NoHashes covers a user who never enrolled recovery
+// codes (or has used them all up).
+func TestConsumeRecoveryCode_NoHashes(t *testing.T) {
+	up := &fakeRecoveryCodeConsumer{}
+	a := &Auth{hasher: identityHasher{}, userProvider: up}
+	user := &models.User{ID: "u1"}
+
+	ok, err := a.consumeRecoveryCode(context.Background(), user, "anything")
+	if err != nil || ok {
+		t.Fatalf("expected no match with zero recovery hashes, ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sso/internal/models"
+)
+
+type fakeRefreshTokenProvider struct {
+	rt models.RefreshToken
+}
+
+func (f *fakeRefreshTokenProvider) RefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	return f.rt, nil
+}
+
+type fakeRefreshTokenRevoker struct {
+	ifActiveOK bool
+
+	chainRevoked bool
+	chainUserID  models.UserID
+	chainAppID   int
+}
+
+func (f *fakeRefreshTokenRevoker) RevokeRefreshToken(ctx context.Context, id, replacedBy models.ID) error {
+	return nil
+}
+
+func (f *fakeRefreshTokenRevoker) RevokeRefreshTokenIfActive(ctx context.Context, id, replacedBy models.ID) (bool, error) {
+	return f.ifActiveOK, nil
+}
+
+func (f *fakeRefreshTokenRevoker) RevokeRefreshTokenChain(ctx context.Context, userID models.UserID, appID int) error {
+	f.chainRevoked = true
+	f.chainUserID = userID
+	f.chainAppID = appID
+	return nil
+}
+
+// TestRefresh_RevokedTokenTriggersChainRevocation covers the reuse-detection
+// path: presenting a refresh token that's already been revoked (because it
+// was rotated or explicitly logged out) must revoke the whole user+app
+// chain, not just fail quietly.
+func TestRefresh_RevokedTokenTriggersChainRevocation(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+	rt := models.RefreshToken{
+		ID:        "rt1",
+		UserID:    "u1",
+		AppID:     1,
+		TokenHash: hashToken("plaintext"),
+		RevokedAt: &revokedAt,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	provider := &fakeRefreshTokenProvider{rt: rt}
+	revoker := &fakeRefreshTokenRevoker{}
+
+	a := &Auth{refreshTokenProvider: provider, refreshTokenRevoker: revoker}
+
+	_, _, err := a.Refresh(context.Background(), "plaintext", 1, "ua", "1.1.1.1")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if !revoker.chainRevoked {
+		t.Fatalf("expected RevokeRefreshTokenChain to be called")
+	}
+	if revoker.chainUserID != "u1" || revoker.chainAppID != 1 {
+		t.Fatalf("chain revoked for wrong user/app: %v/%v", revoker.chainUserID, revoker.chainAppID)
+	}
+}
+
+// TestRefresh_ConcurrentReuseTripsChainRevocation covers the race this atomic
+// revoke closes: if RevokeRefreshTokenIfActive reports it lost the
+// compare-and-swap (another call already revoked the token), that must be
+// treated as reuse and revoke the whole chain, not silently mint a token.
+func TestRefresh_ConcurrentReuseTripsChainRevocation(t *testing.T) {
+	rt := models.RefreshToken{
+		ID:        "rt1",
+		UserID:    "u1",
+		AppID:     1,
+		TokenHash: hashToken("plaintext"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	provider := &fakeRefreshTokenProvider{rt: rt}
+	revoker := &fakeRefreshTokenRevoker{ifActiveOK: false}
+
+	a := &Auth{refreshTokenProvider: provider, refreshTokenRevoker: revoker}
+
+	_, _, err := a.Refresh(context.Background(), "plaintext", 1, "ua", "1.1.1.1")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if !revoker.chainRevoked {
+		t.Fatalf("expected RevokeRefreshTokenChain to be called")
+	}
+	if revoker.chainUserID != "u1" || revoker.chainAppID != 1 {
+		t.Fatalf("chain revoked for wrong user/app: %v/%v", revoker.chainUserID, revoker.chainAppID)
+	}
+}
+
+// TestRefresh_AppMismatchRejected covers a refresh token presented against
+// the wrong app_id, which must be rejected without touching revocation.
+func TestRefresh_AppMismatchRejected(t *testing.T) {
+	rt := models.RefreshToken{
+		ID:        "rt1",
+		UserID:    "u1",
+		AppID:     1,
+		TokenHash: hashToken("plaintext"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	provider := &fakeRefreshTokenProvider{rt: rt}
+	revoker := &fakeRefreshTokenRevoker{}
+
+	a := &Auth{refreshTokenProvider: provider, refreshTokenRevoker: revoker}
+
+	_, _, err := a.Refresh(context.Background(), "plaintext", 2, "ua", "1.1.1.1")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if revoker.chainRevoked {
+		t.Fatalf("app mismatch must not trigger chain revocation")
+	}
+}
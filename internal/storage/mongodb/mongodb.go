@@ -11,8 +11,15 @@ import (
 	"sso/internal/models"
 	"sso/internal/storage"
 	"strings"
+	"time"
 )
 
+func init() {
+	storage.Register("mongo", func(storagePath string) (storage.Storage, error) {
+		return New(storagePath)
+	})
+}
+
 type Storage struct {
 	DB *mongo.Client
 }
@@ -27,32 +34,39 @@ func New(storagePath string) (*Storage, error) {
 	return &Storage{DB: db}, nil
 
 }
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (primitive.ObjectID, error) {
+
+// newID mints a backend-native identifier rendered as the backend-neutral
+// models.ID, so every collection can keep using "_id" as a plain string.
+func newID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (models.UserID, error) {
 	const op = "storage.mongodb.SaveUser"
 
 	collection := s.DB.Database("pizzeria").Collection("users")
 	user := models.User{
+		ID:       models.UserID(newID()),
 		Email:    email,
 		PassHash: passHash,
 	}
-	result, err := collection.InsertOne(ctx, user)
+	_, err := collection.InsertOne(ctx, user)
 	if err != nil {
 		var writeException mongo.WriteException
 		if errors.As(err, &writeException) {
 			for _, we := range writeException.WriteErrors {
 				if we.Code == 11000 {
-					return primitive.NilObjectID, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+					return "", fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 				}
 			}
 		}
 		if strings.Contains(err.Error(), "users_uc_email") {
-			return primitive.NilObjectID, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
-		return primitive.NilObjectID, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	insertedID := result.InsertedID.(primitive.ObjectID)
-	return insertedID, nil
+	return user.ID, nil
 }
 
 func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, error) {
@@ -72,13 +86,89 @@ func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, e
 	return user, nil
 }
 
-func (s *Storage) IsAdmin(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+func (s *Storage) UserByID(ctx context.Context, userID models.UserID) (models.User, error) {
+	const op = "storage.mongodb.UserByID"
+
+	collection := s.DB.Database("pizzeria").Collection("users")
+
+	var user models.User
+	err := collection.FindOne(ctx, bson.M{"_id": string(userID)}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID models.UserID, passHash []byte) error {
+	const op = "storage.mongodb.UpdatePassword"
+
+	collection := s.DB.Database("pizzeria").Collection("users")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": string(userID)},
+		bson.M{"$set": bson.M{"pass_hash": passHash}},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) SetMFASecret(ctx context.Context, userID models.UserID, secretEnc string, recoveryHashes []string) error {
+	const op = "storage.mongodb.SetMFASecret"
+
+	collection := s.DB.Database("pizzeria").Collection("users")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": string(userID)},
+		bson.M{"$set": bson.M{"mfa_secret_enc": secretEnc, "recovery_hashes": recoveryHashes}},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) EnableMFA(ctx context.Context, userID models.UserID) error {
+	const op = "storage.mongodb.EnableMFA"
+
+	collection := s.DB.Database("pizzeria").Collection("users")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": string(userID)},
+		bson.M{"$set": bson.M{"mfa_enabled": true}},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) ConsumeRecoveryCode(ctx context.Context, userID models.UserID, hash string) error {
+	const op = "storage.mongodb.ConsumeRecoveryCode"
+
+	collection := s.DB.Database("pizzeria").Collection("users")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": string(userID)},
+		bson.M{"$pull": bson.M{"recovery_hashes": hash}},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) IsAdmin(ctx context.Context, userID models.UserID) (bool, error) {
 	const op = "storage.mongodb.IsAdmin"
 
 	collection := s.DB.Database("pizzeria").Collection("users")
 
 	var user bool
-	err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	err := collection.FindOne(ctx, bson.M{"_id": string(userID)}).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -104,3 +194,103 @@ func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	}
 	return app, nil
 }
+
+func (s *Storage) refreshTokens() *mongo.Collection {
+	return s.DB.Database("pizzeria").Collection("refresh_tokens")
+}
+
+// EnsureRefreshTokenIndexes creates the TTL index that lets Mongo garbage
+// collect expired refresh tokens on its own. It is idempotent and should be
+// called once on startup.
+func (s *Storage) EnsureRefreshTokenIndexes(ctx context.Context) error {
+	const op = "storage.mongodb.EnsureRefreshTokenIndexes"
+
+	_, err := s.refreshTokens().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, rt models.RefreshToken) error {
+	const op = "storage.mongodb.SaveRefreshToken"
+
+	if rt.ID == "" {
+		rt.ID = models.ID(newID())
+	}
+
+	if _, err := s.refreshTokens().InsertOne(ctx, rt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) RefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.mongodb.RefreshTokenByHash"
+
+	var rt models.RefreshToken
+	err := s.refreshTokens().FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&rt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return rt, nil
+}
+
+func (s *Storage) RevokeRefreshToken(ctx context.Context, id models.ID, replacedBy models.ID) error {
+	const op = "storage.mongodb.RevokeRefreshToken"
+
+	set := bson.M{"revoked_at": time.Now()}
+	if replacedBy != "" {
+		set["replaced_by"] = replacedBy
+	}
+
+	_, err := s.refreshTokens().UpdateOne(ctx, bson.M{"_id": string(id)}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeRefreshTokenIfActive is RevokeRefreshToken's compare-and-swap form:
+// it only revokes id if revoked_at is still unset, so two callers racing to
+// rotate the same token can't both believe they won.
+func (s *Storage) RevokeRefreshTokenIfActive(ctx context.Context, id models.ID, replacedBy models.ID) (bool, error) {
+	const op = "storage.mongodb.RevokeRefreshTokenIfActive"
+
+	set := bson.M{"revoked_at": time.Now()}
+	if replacedBy != "" {
+		set["replaced_by"] = replacedBy
+	}
+
+	res, err := s.refreshTokens().UpdateOne(ctx,
+		bson.M{"_id": string(id), "revoked_at": nil},
+		bson.M{"$set": set})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// RevokeRefreshTokenChain revokes every still-active refresh token for a
+// user+app pair. It is invoked when a rotated/revoked token is presented
+// again, which signals the chain may have been stolen.
+func (s *Storage) RevokeRefreshTokenChain(ctx context.Context, userID models.UserID, appID int) error {
+	const op = "storage.mongodb.RevokeRefreshTokenChain"
+
+	_, err := s.refreshTokens().UpdateMany(
+		ctx,
+		bson.M{"user_id": string(userID), "app_id": appID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"sso/internal/models"
+	"sso/internal/storage"
+)
+
+func init() {
+	storage.Register("sqlite", func(storagePath string) (storage.Storage, error) {
+		return New(storagePath)
+	})
+}
+
+type Storage struct {
+	db *sql.DB
+}
+
+func New(storagePath string) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	db, err := sql.Open("sqlite3", storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// newID generates a random primary key, matching the opaque-token style used
+// for refresh tokens rather than anything derived from the clock: two
+// inserts in the same nanosecond must never collide.
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (models.UserID, error) {
+	const op = "storage.sqlite.SaveUser"
+
+	id, err := newID()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO users (id, email, pass_hash) VALUES (?, ?, ?)", id, email, passHash)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.UserID(id), nil
+}
+
+const userColumns = "id, email, pass_hash, mfa_secret_enc, mfa_enabled, recovery_hashes"
+
+func scanUser(row *sql.Row) (models.User, error) {
+	var (
+		user           models.User
+		recoveryHashes string
+	)
+	if err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.MFASecretEnc, &user.MFAEnabled, &recoveryHashes); err != nil {
+		return models.User{}, err
+	}
+
+	if recoveryHashes != "" {
+		if err := json.Unmarshal([]byte(recoveryHashes), &user.RecoveryHashes); err != nil {
+			return models.User{}, fmt.Errorf("unmarshal recovery_hashes: %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	const op = "storage.sqlite.UserByEmail"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = ?", email)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID models.UserID) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", string(userID))
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID models.UserID, passHash []byte) error {
+	const op = "storage.sqlite.UpdatePassword"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET pass_hash = ? WHERE id = ?", passHash, string(userID))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) SetMFASecret(ctx context.Context, userID models.UserID, secretEnc string, recoveryHashes []string) error {
+	const op = "storage.sqlite.SetMFASecret"
+
+	encoded, err := json.Marshal(recoveryHashes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"UPDATE users SET mfa_secret_enc = ?, recovery_hashes = ? WHERE id = ?",
+		secretEnc, string(encoded), string(userID))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) EnableMFA(ctx context.Context, userID models.UserID) error {
+	const op = "storage.sqlite.EnableMFA"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET mfa_enabled = TRUE WHERE id = ?", string(userID))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) ConsumeRecoveryCode(ctx context.Context, userID models.UserID, hash string) error {
+	const op = "storage.sqlite.ConsumeRecoveryCode"
+
+	row := s.db.QueryRowContext(ctx, "SELECT recovery_hashes FROM users WHERE id = ?", string(userID))
+
+	var encoded string
+	if err := row.Scan(&encoded); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var existing []string
+	if encoded != "" {
+		if err := json.Unmarshal([]byte(encoded), &existing); err != nil {
+			return fmt.Errorf("%s: unmarshal recovery_hashes: %w", op, err)
+		}
+	}
+
+	remaining := make([]string, 0, len(existing))
+	for _, h := range existing {
+		if h != hash {
+			remaining = append(remaining, h)
+		}
+	}
+
+	updated, err := json.Marshal(remaining)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE users SET recovery_hashes = ? WHERE id = ?",
+		string(updated), string(userID))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) IsAdmin(ctx context.Context, userID models.UserID) (bool, error) {
+	const op = "storage.sqlite.IsAdmin"
+
+	row := s.db.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = ?", string(userID))
+
+	var isAdmin bool
+	if err := row.Scan(&isAdmin); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return isAdmin, nil
+}
+
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	const op = "storage.sqlite.App"
+
+	row := s.db.QueryRowContext(ctx, "SELECT id, name, secret FROM apps WHERE id = ?", appID)
+
+	var app models.App
+	if err := row.Scan(&app.ID, &app.Name, &app.Secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// EnsureRefreshTokenIndexes is a no-op for SQLite, which has no native TTL
+// feature: the migrations create the table's indexes, and expired refresh
+// tokens stay in the table (Refresh rejects them by expires_at) since
+// nothing deletes them.
+func (s *Storage) EnsureRefreshTokenIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, rt models.RefreshToken) error {
+	const op = "storage.sqlite.SaveRefreshToken"
+
+	id := string(rt.ID)
+	if id == "" {
+		var err error
+		id, err = newID()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, user_id, app_id, token_hash, issued_at, expires_at, user_agent, ip)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, string(rt.UserID), rt.AppID, rt.TokenHash, rt.IssuedAt, rt.ExpiresAt, rt.UserAgent, rt.IP)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.sqlite.RefreshTokenByHash"
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, app_id, token_hash, issued_at, expires_at, revoked_at, replaced_by, user_agent, ip
+		 FROM refresh_tokens WHERE token_hash = ?`, tokenHash)
+
+	var (
+		rt         models.RefreshToken
+		revokedAt  sql.NullTime
+		replacedBy sql.NullString
+	)
+	err := row.Scan(&rt.ID, &rt.UserID, &rt.AppID, &rt.TokenHash, &rt.IssuedAt, &rt.ExpiresAt,
+		&revokedAt, &replacedBy, &rt.UserAgent, &rt.IP)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if revokedAt.Valid {
+		rt.RevokedAt = &revokedAt.Time
+	}
+	rt.ReplacedBy = models.ID(replacedBy.String)
+
+	return rt, nil
+}
+
+func (s *Storage) RevokeRefreshToken(ctx context.Context, id models.ID, replacedBy models.ID) error {
+	const op = "storage.sqlite.RevokeRefreshToken"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ?, replaced_by = ? WHERE id = ?",
+		time.Now(), nullableID(replacedBy), string(id))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRefreshTokenIfActive is RevokeRefreshToken's compare-and-swap form:
+// it only revokes id if revoked_at is still NULL, so two callers racing to
+// rotate the same token can't both believe they won.
+func (s *Storage) RevokeRefreshTokenIfActive(ctx context.Context, id models.ID, replacedBy models.ID) (bool, error) {
+	const op = "storage.sqlite.RevokeRefreshTokenIfActive"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ?, replaced_by = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now(), nullableID(replacedBy), string(id))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rows > 0, nil
+}
+
+func (s *Storage) RevokeRefreshTokenChain(ctx context.Context, userID models.UserID, appID int) error {
+	const op = "storage.sqlite.RevokeRefreshTokenChain"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND app_id = ? AND revoked_at IS NULL",
+		time.Now(), string(userID), appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func nullableID(id models.ID) interface{} {
+	if id == "" {
+		return nil
+	}
+	return string(id)
+}
@@ -0,0 +1,99 @@
+// Package storage defines the storage-backend contract shared by every
+// implementation (mongodb, sqlite, ...) and a Factory that builds one from
+// config, so callers never import a concrete driver package directly.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sso/internal/models"
+)
+
+var (
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = errors.New("user not found")
+	ErrAppNotFound  = errors.New("app not found")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+)
+
+type UserSaver interface {
+	SaveUser(ctx context.Context, email string, passHash []byte) (models.UserID, error)
+}
+
+type UserProvider interface {
+	UserByEmail(ctx context.Context, email string) (models.User, error)
+	UserByID(ctx context.Context, userID models.UserID) (models.User, error)
+	IsAdmin(ctx context.Context, userID models.UserID) (bool, error)
+	UpdatePassword(ctx context.Context, userID models.UserID, passHash []byte) error
+
+	// SetMFASecret stores the (encrypted) TOTP secret and recovery code
+	// hashes generated by enrollment, without yet enabling MFA.
+	SetMFASecret(ctx context.Context, userID models.UserID, secretEnc string, recoveryHashes []string) error
+	// EnableMFA flips mfa_enabled on once ConfirmTOTP verifies a code.
+	EnableMFA(ctx context.Context, userID models.UserID) error
+	// ConsumeRecoveryCode removes a single matched recovery hash, making it
+	// unusable again.
+	ConsumeRecoveryCode(ctx context.Context, userID models.UserID, hash string) error
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+type RefreshTokenSaver interface {
+	SaveRefreshToken(ctx context.Context, rt models.RefreshToken) error
+}
+
+type RefreshTokenProvider interface {
+	RefreshTokenByHash(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+}
+
+type RefreshTokenRevoker interface {
+	RevokeRefreshToken(ctx context.Context, id models.ID, replacedBy models.ID) error
+	// RevokeRefreshTokenIfActive atomically revokes id, but only if it
+	// hasn't already been revoked by a concurrent call; ok reports whether
+	// this call was the one that revoked it. A false ok with a nil error
+	// means someone else revoked id first — i.e. the token was reused.
+	RevokeRefreshTokenIfActive(ctx context.Context, id models.ID, replacedBy models.ID) (ok bool, err error)
+	RevokeRefreshTokenChain(ctx context.Context, userID models.UserID, appID int) error
+}
+
+// Storage is the full contract a backend must satisfy to be selected by Factory.
+type Storage interface {
+	UserSaver
+	UserProvider
+	AppProvider
+	RefreshTokenSaver
+	RefreshTokenProvider
+	RefreshTokenRevoker
+	EnsureRefreshTokenIndexes(ctx context.Context) error
+}
+
+// Factory builds a Storage from the backend-specific storagePath (a Mongo
+// URI, a SQLite file path, ...), the same string main already passes to
+// mongodb.New today.
+type Factory func(storagePath string) (Storage, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a backend available under the given storage.type config
+// value. Backend packages call this from an init() so that selecting a
+// backend is just a blank import plus a config key, mirroring how
+// database/sql drivers register themselves.
+func Register(typ string, f Factory) {
+	factories[typ] = f
+}
+
+// New builds the backend named by typ (storage.type in config.yaml) against
+// storagePath. "mongo" and "sqlite" are the backends that register
+// themselves; any other typ returns an error.
+func New(typ, storagePath string) (Storage, error) {
+	f, ok := factories[typ]
+	if !ok {
+		return nil, fmt.Errorf("storage: unknown or unregistered backend %q", typ)
+	}
+	return f(storagePath)
+}